@@ -86,11 +86,55 @@ func BlockAsString(vm *VM, target, locals Interface, msg *Message) Interface {
 		b.WriteByte(',')
 	}
 	b.WriteByte('\n')
-	blk.Message.stringRecurse(vm, &b)
+	writeStatementsWithComments(vm, &b, blk.Message)
 	b.WriteString("\n)")
 	return vm.NewString(b.String())
 }
 
+// writeStatementsWithComments writes msg's Next-chain of statements to b, one
+// per line, preceding each statement with its leading comments (see the
+// comment-lexing request) so they survive round-tripping through asString
+// the same way the formatter's statementList reproduces them. It splits on
+// SemiSym the same way statementList does, temporarily detaching each
+// statement's Next link so stringRecurse only renders that one statement.
+func writeStatementsWithComments(vm *VM, b *bytes.Buffer, msg *Message) {
+	first := true
+	for stmt := msg; stmt != nil; {
+		end := stmt
+		for end.Next != nil && end.Next.Symbol.Kind != SemiSym {
+			end = end.Next
+		}
+		sep := end.Next
+		end.Next = nil
+
+		if !first {
+			b.WriteByte('\n')
+		}
+		first = false
+		for _, c := range stmt.Comments {
+			b.WriteString(c)
+			b.WriteByte('\n')
+		}
+		stmt.stringRecurse(vm, b)
+		// sep, the SemiSym separating stmt from the next statement, is where
+		// a same-line trailing comment ("a := 1 # trailing") ends up
+		// attached, since it's the first Message the lexer sees after the
+		// comment.
+		if sep != nil {
+			for _, c := range sep.Comments {
+				b.WriteByte(' ')
+				b.WriteString(c)
+			}
+		}
+
+		end.Next = sep
+		if sep == nil {
+			return
+		}
+		stmt = sep.Next
+	}
+}
+
 func BlockCall(vm *VM, target, locals Interface, msg *Message) Interface {
 	return target.(*Block).reallyActivate(vm, target, locals, msg)
 }
@@ -0,0 +1,55 @@
+// Command iofmt reformats Io source files to canonical style, the way gofmt
+// does for Go: run with one or more file paths to rewrite them in place, or
+// with no arguments to format stdin to stdout.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/genko/iolang"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		if err := formatReader(os.Stdin, os.Stdout, ""); err != nil {
+			fmt.Fprintln(os.Stderr, "iofmt:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	status := 0
+	for _, path := range os.Args[1:] {
+		if err := formatFile(path); err != nil {
+			fmt.Fprintln(os.Stderr, "iofmt:", err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func formatFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var out bytes.Buffer
+	if err := formatReader(in, &out, path); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+func formatReader(in io.Reader, out io.Writer, file string) error {
+	vm := iolang.NewVM()
+	msg, err := vm.ParseFile(file, in)
+	if err != nil {
+		return err
+	}
+	vm.OpShuffle(msg)
+	return vm.Format(msg, out, iolang.FormatOptions{})
+}
@@ -0,0 +1,318 @@
+package iolang
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FormatOptions controls how VM.Format renders a message chain.
+type FormatOptions struct {
+	// IndentWidth is the number of spaces per indentation level. Zero uses
+	// the default of 4.
+	IndentWidth int
+	// ColumnBudget is the line length past which a call's arguments are
+	// split one per line instead of being joined with ", ". Zero uses the
+	// default of 80.
+	ColumnBudget int
+}
+
+func (o FormatOptions) indentWidth() int {
+	if o.IndentWidth > 0 {
+		return o.IndentWidth
+	}
+	return 4
+}
+
+func (o FormatOptions) columnBudget() int {
+	if o.ColumnBudget > 0 {
+		return o.ColumnBudget
+	}
+	return 80
+}
+
+// Format writes msg to w as canonically formatted Io source: one statement
+// per line, operators printed infix per vm's OperatorTable, assignments in
+// the same block column-aligned, and a call's arguments split one per line
+// once they would run past opts' column budget. This is the engine behind
+// the iofmt command and Message asFormattedString.
+func (vm *VM) Format(msg *Message, w io.Writer, opts FormatOptions) error {
+	bw := bufio.NewWriter(w)
+	f := &formatter{
+		ot:     vm.OperatorTable(),
+		w:      bw,
+		indent: opts.indentWidth(),
+		budget: opts.columnBudget(),
+	}
+	f.statementList(msg, 0)
+	return bw.Flush()
+}
+
+type formatter struct {
+	ot     *OperatorTable
+	w      *bufio.Writer
+	indent int
+	budget int
+	col    int
+
+	// alignedAssignWidth is the slot-name column width to pad to for the
+	// assignment statement currently being printed, set by statementList.
+	alignedAssignWidth int
+}
+
+func (f *formatter) writeString(s string) {
+	f.w.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		f.col = len(s) - i - 1
+	} else {
+		f.col += len(s)
+	}
+}
+
+func (f *formatter) writeByte(b byte) {
+	f.w.WriteByte(b)
+	if b == '\n' {
+		f.col = 0
+	} else {
+		f.col++
+	}
+}
+
+func (f *formatter) newline(depth int) {
+	f.writeByte('\n')
+	f.writeString(strings.Repeat(" ", depth*f.indent))
+}
+
+func (f *formatter) writeComments(m *Message, depth int) {
+	for _, c := range m.Comments {
+		f.writeString(c)
+		f.newline(depth)
+	}
+}
+
+// writeTrailingComments writes the comments the lexer attached to a
+// statement's SemiSym separator, i.e. a comment sharing the source line
+// with the statement it follows (e.g. "a := 1 # trailing"), rather than
+// leading the next statement.
+func (f *formatter) writeTrailingComments(sep *Message) {
+	if sep == nil {
+		return
+	}
+	for _, c := range sep.Comments {
+		f.writeByte(' ')
+		f.writeString(c)
+	}
+}
+
+// statementList formats the chain of statements in msg (a Next-chain split
+// on SemiSym messages), one per line at the given indent depth.
+func (f *formatter) statementList(msg *Message, depth int) {
+	first := true
+	for stmt := msg; stmt != nil; {
+		end := stmt
+		for end.Next != nil && end.Next.Symbol.Kind != SemiSym {
+			end = end.Next
+		}
+		sep := end.Next
+		end.Next = nil
+
+		if !first {
+			f.newline(depth)
+		}
+		first = false
+		f.writeComments(stmt, depth)
+		f.writeString(strings.Repeat(" ", depth*f.indent))
+		f.alignedAssignWidth = assignRunWidth(f.ot, stmt, sep)
+		f.expression(stmt, depth)
+		f.writeTrailingComments(sep)
+
+		end.Next = sep
+		if sep == nil {
+			return
+		}
+		stmt = sep.Next
+	}
+}
+
+// assignRunWidth looks ahead from stmt (by way of the chain of statements
+// starting at sep) to find how many further consecutive statements are also
+// simple assignments, and returns the width to pad the widest slot name in
+// that run to, so a block of assignments lines up its operators in a
+// column, as gofmt does for adjacent const/var declarations. It returns 0
+// if stmt is not itself a simple assignment.
+func assignRunWidth(ot *OperatorTable, stmt, sep *Message) int {
+	name, _, _, ok := assignParts(ot, stmt)
+	if !ok {
+		return 0
+	}
+	width := len(name)
+	for sep != nil {
+		next := sep.Next
+		end := next
+		for end != nil && end.Next != nil && end.Next.Symbol.Kind != SemiSym {
+			end = end.Next
+		}
+		if next == nil {
+			break
+		}
+		var nextSep *Message
+		if end != nil {
+			nextSep = end.Next
+		}
+		n, _, _, ok := assignParts(ot, next)
+		if !ok {
+			break
+		}
+		if len(n) > width {
+			width = len(n)
+		}
+		sep = nextSep
+	}
+	return width
+}
+
+// assignParts reports whether m is a rewritten assignment (setSlot/
+// updateSlot/newSlot over a literal name), returning the slot name, the
+// operator text it came from, and the value expression.
+func assignParts(ot *OperatorTable, m *Message) (name, op string, value *Message, ok bool) {
+	if ot == nil || m == nil || m.Next != nil || len(m.Args) != 2 {
+		return "", "", nil, false
+	}
+	if m.Args[0].Symbol.Kind != StringSym {
+		return "", "", nil, false
+	}
+	for o, method := range ot.AssignOperators {
+		if method == m.Symbol.Text {
+			return m.Args[0].Symbol.String, o, m.Args[1], true
+		}
+	}
+	return "", "", nil, false
+}
+
+// expression formats a single statement's message chain: messages linked by
+// Next, space-separated, with operator messages rendered infix.
+func (f *formatter) expression(msg *Message, depth int) {
+	if name, op, value, ok := assignParts(f.ot, msg); ok {
+		f.writeString(name)
+		if pad := f.alignedAssignWidth - len(name); pad > 0 {
+			f.writeString(strings.Repeat(" ", pad))
+		}
+		f.writeByte(' ')
+		f.writeString(op)
+		f.writeByte(' ')
+		f.expression(value, depth)
+		return
+	}
+	first := true
+	for m := msg; m != nil; m = m.Next {
+		if !first {
+			f.writeByte(' ')
+		}
+		first = false
+		f.message(m, depth)
+	}
+}
+
+func (f *formatter) message(m *Message, depth int) {
+	switch m.Symbol.Kind {
+	case NumSym:
+		f.writeString(strconv.FormatFloat(m.Symbol.Num, 'g', -1, 64))
+		return
+	case StringSym:
+		f.writeString(strconv.Quote(m.Symbol.String))
+		return
+	}
+	switch m.Symbol.Text {
+	case "":
+		if m.Symbol.Kind == IdentSym && len(m.Args) == 1 {
+			// An explicitly parenthesized subexpression.
+			f.writeByte('(')
+			f.expression(m.Args[0], depth)
+			f.writeByte(')')
+			return
+		}
+	case "squareBrackets":
+		f.writeByte('[')
+		f.argList(m.Args, depth)
+		f.writeByte(']')
+		return
+	case "curlyBrackets":
+		f.writeByte('{')
+		f.argList(m.Args, depth)
+		f.writeByte('}')
+		return
+	}
+	if f.ot != nil && len(m.Args) == 1 {
+		if _, isAssign := f.ot.AssignOperators[m.Symbol.Text]; !isAssign && f.ot.isOperator(m.Symbol.Text) {
+			f.writeString(m.Symbol.Text)
+			f.writeByte(' ')
+			f.expression(m.Args[0], depth)
+			return
+		}
+	}
+	f.writeString(m.Symbol.Text)
+	if len(m.Args) > 0 {
+		f.writeByte('(')
+		f.argList(m.Args, depth)
+		f.writeByte(')')
+	}
+}
+
+// argList formats a call's arguments, joined with ", " if they fit within
+// the column budget and one per indented line otherwise.
+func (f *formatter) argList(args []*Message, depth int) {
+	if len(args) == 0 {
+		return
+	}
+	if f.fitsOnLine(args, depth) {
+		for i, arg := range args {
+			if i > 0 {
+				f.writeString(", ")
+			}
+			f.expression(arg, depth)
+		}
+		return
+	}
+	inner := depth + 1
+	for _, arg := range args {
+		f.newline(inner)
+		f.expression(arg, inner)
+		f.writeByte(',')
+	}
+	f.newline(depth)
+}
+
+// fitsOnLine reports whether args, joined with ", ", would fit within the
+// formatter's column budget if printed starting at the current column.
+func (f *formatter) fitsOnLine(args []*Message, depth int) bool {
+	var b strings.Builder
+	sub := &formatter{ot: f.ot, w: bufio.NewWriter(&b), indent: f.indent, budget: f.budget}
+	for i, arg := range args {
+		if i > 0 {
+			sub.writeString(", ")
+		}
+		sub.expression(arg, depth)
+	}
+	sub.w.Flush()
+	return !strings.Contains(b.String(), "\n") && f.col+b.Len()+2 <= f.budget
+}
+
+// MessageAsFormattedString is a Message method returning target reformatted
+// via VM.Format with the default FormatOptions: a gofmt-style companion to
+// asString, for use by e.g. the iofmt command.
+func MessageAsFormattedString(vm *VM, target, locals Interface, msg *Message) Interface {
+	m := target.(*Message)
+	var b strings.Builder
+	if err := vm.Format(m, &b, FormatOptions{}); err != nil {
+		return vm.IoError(err)
+	}
+	return vm.NewString(b.String())
+}
+
+func (vm *VM) initMessageFormat() {
+	if vm.DefaultSlots["Message"] == nil {
+		vm.DefaultSlots["Message"] = Slots{}
+	}
+	vm.DefaultSlots["Message"]["asFormattedString"] = vm.NewCFunction(MessageAsFormattedString, "MessageAsFormattedString()")
+}
@@ -0,0 +1,40 @@
+package iolang
+
+import (
+	"strings"
+	"testing"
+)
+
+// formatShuffled parses and op-shuffles src, then formats the result with
+// the default FormatOptions, mirroring what DoReader and iofmt's
+// formatReader do before handing a Message chain to VM.Format.
+func formatShuffled(t *testing.T, src string) string {
+	t.Helper()
+	vm := NewVM()
+	msg, err := vm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", src, err)
+	}
+	vm.OpShuffle(msg)
+	var b strings.Builder
+	if err := vm.Format(msg, &b, FormatOptions{}); err != nil {
+		t.Fatalf("Format(%q) error: %v", src, err)
+	}
+	return b.String()
+}
+
+func TestFormatLiterals(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"1 + 2 * 3", "1 + 2 * 3"},
+		{"foo(1, 2, 3)", "foo(1, 2, 3)"},
+		{`foo("bar")`, `foo("bar")`},
+		{"a := 1", "a := 1"},
+	}
+	for _, tt := range tests {
+		if got := formatShuffled(t, tt.src); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package iolang
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 )
@@ -12,7 +13,13 @@ type token struct {
 	Value string
 	Err   error
 
-	// Line, Col int
+	File      string
+	Line, Col int
+}
+
+// Position returns the source position at which this token begins.
+func (t token) Position() Position {
+	return Position{File: t.File, Line: t.Line, Col: t.Col}
 }
 
 type tokenKind int
@@ -28,24 +35,60 @@ const (
 	hexToken
 	stringToken
 	triquoteToken
+	commentToken
 )
 
-type lexFn func(src *bufio.Reader, tokens chan<- token) lexFn
+type lexFn func(l *lexState) lexFn
+
+// lexState tracks the reader and running source position for a lex pass, so
+// the individual lexFns don't each need to thread file/line/col by hand.
+type lexState struct {
+	src    *bufio.Reader
+	tokens chan<- token
+	file   string
+	line   int
+	col    int
+}
+
+// readRune reads the next rune from l, returning the position at which it
+// was found, and advances l's position past it.
+func (l *lexState) readRune() (r rune, line, col int, err error) {
+	line, col = l.line, l.col
+	r, _, err = l.src.ReadRune()
+	if err != nil {
+		return
+	}
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return
+}
+
+// unreadRune undoes the last readRune, restoring l's position to line, col,
+// which must be the position that readRune returned for that rune.
+func (l *lexState) unreadRune(line, col int) {
+	l.src.UnreadRune()
+	l.line, l.col = line, col
+}
 
-func lex(src *bufio.Reader, tokens chan<- token) {
+func lex(file string, src *bufio.Reader, tokens chan<- token) {
+	l := &lexState{src: src, tokens: tokens, file: file, line: 1, col: 1}
 	state := eatSpace
 	for state != nil {
-		state = state(src, tokens)
+		state = state(l)
 	}
 	close(tokens)
 }
 
-// Append the next run of characters in src which satisfy the predicate to b.
+// Append the next run of characters in l which satisfy the predicate to b.
 // Returns b after appending, the first rune which did not satisfy the
 // predicate, and any error that occurred. Iff there was no such error, the
 // last rune is unread.
-func accept(src *bufio.Reader, predicate func(rune) bool, b []byte) ([]byte, rune, error) {
-	r, _, err := src.ReadRune()
+func (l *lexState) accept(predicate func(rune) bool, b []byte) ([]byte, rune, error) {
+	r, line, col, err := l.readRune()
 	for {
 		if err != nil {
 			return b, r, err
@@ -54,34 +97,38 @@ func accept(src *bufio.Reader, predicate func(rune) bool, b []byte) ([]byte, run
 			break
 		}
 		b = append(b, string(r)...)
-		r, _, err = src.ReadRune()
+		r, line, col, err = l.readRune()
 	}
-	src.UnreadRune()
+	l.unreadRune(line, col)
 	return b, r, nil
 }
 
-func lexsend(err error, tokens chan<- token, good token) lexFn {
+func lexsend(err error, l *lexState, good token) lexFn {
+	good.File = l.file
 	if err != nil && err != io.EOF {
 		good.Kind = badToken
 		good.Err = err
 	}
-	tokens <- good
+	l.tokens <- good
 	if err != nil {
 		return nil
 	}
 	return eatSpace
 }
 
-func eatSpace(src *bufio.Reader, tokens chan<- token) lexFn {
+func eatSpace(l *lexState) lexFn {
 	// Could use accept here, but I've already written this.
-	r, _, err := src.ReadRune()
+	r, line, col, err := l.readRune()
 	for {
 		if err != nil {
 			if err != io.EOF {
-				tokens <- token{
+				l.tokens <- token{
 					Kind:  badToken,
 					Value: string(r),
 					Err:   err,
+					File:  l.file,
+					Line:  line,
+					Col:   col,
 				}
 			}
 			return nil
@@ -89,160 +136,251 @@ func eatSpace(src *bufio.Reader, tokens chan<- token) lexFn {
 		if !strings.ContainsRune(" \r\f\t\v", r) {
 			break
 		}
-		r, _, err = src.ReadRune()
+		r, line, col, err = l.readRune()
 	}
 	switch {
 	case r == ';', r == '\n':
-		tokens <- token{
+		l.tokens <- token{
 			Kind:  semiToken,
 			Value: string(r),
+			File:  l.file,
+			Line:  line,
+			Col:   col,
 		}
 		return eatSpace
 	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '_', r >= 0x80:
-		src.UnreadRune()
+		l.unreadRune(line, col)
 		return lexIdent
+	case r == '#':
+		l.unreadRune(line, col)
+		return lexLineComment
+	case r == '/':
+		// / can start an operator, a line comment, or a block comment.
+		peek, _ := l.src.Peek(1)
+		l.unreadRune(line, col)
+		if len(peek) > 0 && peek[0] == '/' {
+			return lexLineComment
+		}
+		if len(peek) > 0 && peek[0] == '*' {
+			return lexBlockComment
+		}
+		return lexOp
 	case strings.ContainsRune("!$%&'*+-/:<=>?@\\^|~", r):
-		src.UnreadRune()
+		l.unreadRune(line, col)
 		return lexOp
 	case strings.ContainsRune("([{", r):
-		tokens <- token{
+		l.tokens <- token{
 			Kind:  openToken,
 			Value: string(r),
+			File:  l.file,
+			Line:  line,
+			Col:   col,
 		}
 		return eatSpace
 	case strings.ContainsRune(")]}", r):
-		tokens <- token{
+		l.tokens <- token{
 			Kind:  closeToken,
 			Value: string(r),
+			File:  l.file,
+			Line:  line,
+			Col:   col,
 		}
 		return eatSpace
 	case r == ',':
-		tokens <- token{
+		l.tokens <- token{
 			Kind:  commaToken,
 			Value: ",",
+			File:  l.file,
+			Line:  line,
+			Col:   col,
 		}
 		return eatSpace
 	case '0' <= r && r <= '9':
-		src.UnreadRune()
+		l.unreadRune(line, col)
 		return lexNumber
 	case r == '.':
 		// . can be either a number or an identifier, because Dumbledore.
-		src.UnreadRune()
-		peek, _ := src.Peek(2)
+		l.unreadRune(line, col)
+		peek, _ := l.src.Peek(2)
 		if len(peek) > 1 && '0' <= peek[1] && peek[1] <= '9' {
 			return lexNumber
 		}
 		return lexIdent
 	case r == '"':
-		src.UnreadRune()
+		l.unreadRune(line, col)
 		return lexString
 	}
 	panic(r)
 }
 
-func lexIdent(src *bufio.Reader, tokens chan<- token) lexFn {
-	b, _, err := accept(src, func(r rune) bool {
+func lexIdent(l *lexState) lexFn {
+	line, col := l.line, l.col
+	b, _, err := l.accept(func(r rune) bool {
 		return 'a' <= r && r <= 'z' ||
 			'A' <= r && r <= 'Z' ||
 			'0' <= r && r <= '9' ||
 			r == '_' || r == '.' || r >= 0x80
 	}, nil)
-	return lexsend(err, tokens, token{Kind: identToken, Value: string(b)})
+	return lexsend(err, l, token{Kind: identToken, Value: string(b), Line: line, Col: col})
 }
 
-func lexOp(src *bufio.Reader, tokens chan<- token) lexFn {
-	b, _, err := accept(src, func(r rune) bool {
+func lexOp(l *lexState) lexFn {
+	line, col := l.line, l.col
+	b, _, err := l.accept(func(r rune) bool {
 		return strings.ContainsRune("!$%&'*+-/:<=>?@\\^|~", r)
 	}, nil)
-	return lexsend(err, tokens, token{Kind: identToken, Value: string(b)})
+	return lexsend(err, l, token{Kind: identToken, Value: string(b), Line: line, Col: col})
 }
 
-func lexNumber(src *bufio.Reader, tokens chan<- token) lexFn {
-	b, r, err := accept(src, func(r rune) bool { return '0' <= r && r <= '9' }, nil)
+// isDecDigit matches a decimal digit or the "_" digit-separator that may
+// appear between digits of any numeric literal, e.g. "1_000_000".
+func isDecDigit(r rune) bool { return '0' <= r && r <= '9' || r == '_' }
+
+func lexNumber(l *lexState) lexFn {
+	line, col := l.line, l.col
+	b, r, err := l.accept(isDecDigit, nil)
 	if err != nil {
-		return lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+		return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 	}
-	if r == 'x' || r == 'X' {
+	switch r {
+	case 'x', 'X':
 		b = append(b, 'x')
-		b, _, err = accept(src, func(r rune) bool {
-			return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+		_, _, _, err = l.readRune()
+		if err != nil {
+			return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
+		}
+		b, _, err = l.accept(func(r rune) bool {
+			return isDecDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
 		}, b)
-		lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+		return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
+	case 'b', 'B':
+		b = append(b, 'b')
+		_, _, _, err = l.readRune()
+		if err != nil {
+			return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
+		}
+		b, _, err = l.accept(func(r rune) bool { return r == '0' || r == '1' || r == '_' }, b)
+		return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
+	case 'o', 'O':
+		b = append(b, 'o')
+		_, _, _, err = l.readRune()
+		if err != nil {
+			return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
+		}
+		b, _, err = l.accept(func(r rune) bool { return '0' <= r && r <= '7' || r == '_' }, b)
+		return lexsend(err, l, token{Kind: hexToken, Value: string(b), Line: line, Col: col})
 	}
 	if r == '.' {
 		b = append(b, '.')
-		_, _, err = src.ReadRune()
+		_, _, _, err = l.readRune()
 		if err != nil {
-			return lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+			return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 		}
-		b, r, err = accept(src, func(r rune) bool { return '0' <= r && r <= '9' }, b)
+		b, r, err = l.accept(isDecDigit, b)
 		if err != nil {
-			return lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+			return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 		}
 	}
 	if r == 'e' || r == 'E' {
-		r, _, err = src.ReadRune()
+		_, _, _, err = l.readRune()
 		if err != nil {
-			return lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
+		}
+		b = append(b, 'e')
+		var sline, scol int
+		r, sline, scol, err = l.readRune()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 		}
 		if r == '-' || r == '+' {
-			r, _, err = src.ReadRune()
-			b = append(b, 'e', byte(r))
-		} else {
-			b = append(b, 'e')
+			b = append(b, byte(r))
+			r, sline, scol, err = l.readRune()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
+			}
+		}
+		if r < '0' || r > '9' {
+			// "1e", "1e+", "1ex": no digits after 'e'/'E' (and its optional
+			// sign), so there's nothing to exponentiate by.
+			err = fmt.Errorf("%s: malformed exponent in numeric literal", Position{File: l.file, Line: line, Col: col})
+			return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 		}
-		b, _, err = accept(src, func(r rune) bool { return '0' <= r && r <= '9' }, b)
+		l.unreadRune(sline, scol)
+		b, _, err = l.accept(isDecDigit, b)
 	}
-	return lexsend(err, tokens, token{Kind: numberToken, Value: string(b)})
+	return lexsend(err, l, token{Kind: numberToken, Value: string(b), Line: line, Col: col})
 }
 
-func lexString(src *bufio.Reader, tokens chan<- token) lexFn {
-	peek, _ := src.Peek(3)
+func lexString(l *lexState) lexFn {
+	peek, _ := l.src.Peek(3)
 	if bytes.Equal(peek, []byte{'"', '"', '"'}) {
-		return lexTriquote(src, tokens)
+		return lexTriquote(l)
 	}
-	return lexMonoquote(src, tokens)
+	return lexMonoquote(l)
 }
 
-func lexTriquote(src *bufio.Reader, tokens chan<- token) lexFn {
-	b := make([]byte, 3, 6)
-	src.Read(b)
+func lexTriquote(l *lexState) lexFn {
+	line, col := l.line, l.col
+	b := make([]byte, 0, 6)
+	for i := 0; i < 3; i++ {
+		r, _, _, _ := l.readRune()
+		b = append(b, string(r)...)
+	}
 	for {
-		r, _, err := src.ReadRune()
+		r, _, _, err := l.readRune()
 		if err != nil {
 			if err == io.EOF {
 				err = io.ErrUnexpectedEOF
 			}
-			tokens <- token{
+			l.tokens <- token{
 				Kind:  badToken,
 				Value: string(b),
 				Err:   err,
+				File:  l.file,
+				Line:  line,
+				Col:   col,
 			}
 			return nil
 		}
 		if r == '"' {
-			peek, err := src.Peek(2)
+			peek, err := l.src.Peek(2)
 			if bytes.Equal(peek, []byte{'"', '"'}) {
-				return lexsend(err, tokens, token{Kind: triquoteToken, Value: string(b) + `"""`})
+				l.src.Discard(2)
+				l.col += 2
+				return lexsend(err, l, token{Kind: triquoteToken, Value: string(b) + `"""`, Line: line, Col: col})
 			}
 		}
 		b = append(b, string(r)...)
 	}
 }
 
-func lexMonoquote(src *bufio.Reader, tokens chan<- token) lexFn {
-	b := make([]byte, 1, 2)
-	src.Read(b)
+func lexMonoquote(l *lexState) lexFn {
+	line, col := l.line, l.col
+	r0, _, _, _ := l.readRune()
+	b := make([]byte, 0, 2)
+	b = append(b, string(r0)...)
 	for {
-		r, _, err := src.ReadRune()
+		r, _, _, err := l.readRune()
 		if err != nil {
 			if err == io.EOF {
 				err = io.ErrUnexpectedEOF
 			}
-			tokens <- token{
+			l.tokens <- token{
 				Kind:  badToken,
 				Value: string(b),
 				Err:   err,
+				File:  l.file,
+				Line:  line,
+				Col:   col,
 			}
 			return nil
 		}
@@ -251,7 +389,65 @@ func lexMonoquote(src *bufio.Reader, tokens chan<- token) lexFn {
 			continue
 		}
 		if r == '"' {
-			return lexsend(err, tokens, token{Kind: stringToken, Value: string(b)})
+			return lexsend(err, l, token{Kind: stringToken, Value: string(b), Line: line, Col: col})
+		}
+	}
+}
+
+// lexLineComment lexes a "#" or "//" comment, up to but not including the
+// terminating newline (or EOF).
+func lexLineComment(l *lexState) lexFn {
+	line, col := l.line, l.col
+	b, _, err := l.accept(func(r rune) bool { return r != '\n' }, nil)
+	return lexsend(err, l, token{Kind: commentToken, Value: string(b), Line: line, Col: col})
+}
+
+// lexBlockComment lexes a "/* ... */" comment, which nests: a "/*" inside
+// the comment requires a matching "*/" before the outer one closes, as in
+// Io itself.
+func lexBlockComment(l *lexState) lexFn {
+	line, col := l.line, l.col
+	// Consume the opening "/*"; it doesn't count toward the nesting depth.
+	r0, _, _, _ := l.readRune()
+	r1, _, _, _ := l.readRune()
+	b := []byte{byte(r0), byte(r1)}
+	depth := 0
+	for {
+		r, _, _, err := l.readRune()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			l.tokens <- token{
+				Kind:  badToken,
+				Value: string(b),
+				Err:   err,
+				File:  l.file,
+				Line:  line,
+				Col:   col,
+			}
+			return nil
+		}
+		b = append(b, string(r)...)
+		if r == '*' {
+			peek, _ := l.src.Peek(1)
+			if len(peek) > 0 && peek[0] == '/' {
+				l.src.Discard(1)
+				l.col++
+				b = append(b, '/')
+				if depth == 0 {
+					return lexsend(nil, l, token{Kind: commentToken, Value: string(b), Line: line, Col: col})
+				}
+				depth--
+			}
+		} else if r == '/' {
+			peek, _ := l.src.Peek(1)
+			if len(peek) > 0 && peek[0] == '*' {
+				l.src.Discard(1)
+				l.col++
+				b = append(b, '*')
+				depth++
+			}
 		}
 	}
 }
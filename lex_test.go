@@ -0,0 +1,95 @@
+package iolang
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// lexAll runs the lexer over src and collects every token it emits.
+func lexAll(src string) []token {
+	tokens := make(chan token)
+	go lex("", bufio.NewReader(strings.NewReader(src)), tokens)
+	var got []token
+	for tok := range tokens {
+		got = append(got, tok)
+	}
+	return got
+}
+
+// TestLexPositions checks that Line and Col are both 1-indexed and that Col
+// counts runes, not bytes, across newlines, tabs, and multi-byte runes.
+func TestLexPositions(t *testing.T) {
+	tests := []struct {
+		name           string
+		src            string
+		wantLine, want int
+	}{
+		{"first token starts at 1:1", "foo", 1, 1},
+		{"token after a space", "foo bar", 1, 5},
+		{"token on the second line starts at col 1", "foo\nbar", 2, 1},
+		{"tab counts as a single column, not an expansion", "a\tb", 1, 3},
+		{"multi-byte rune counts as a single column", "α β", 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toks := lexAll(tt.src)
+			last := toks[len(toks)-1]
+			if last.Line != tt.wantLine || last.Col != tt.want {
+				t.Errorf("lexAll(%q) last token = %+v, want line %d col %d", tt.src, last, tt.wantLine, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		kind    tokenKind
+		value   string
+		wantErr bool
+	}{
+		{"decimal", "42", numberToken, "42", false},
+		{"decimal with separators", "1_000_000", numberToken, "1_000_000", false},
+		{"float", "3.14", numberToken, "3.14", false},
+		{"exponent", "1e10", numberToken, "1e10", false},
+		{"exponent plus", "1e+10", numberToken, "1e+10", false},
+		{"exponent minus", "1e-10", numberToken, "1e-10", false},
+		{"float exponent minus", "1.5e-3", numberToken, "1.5e-3", false},
+		{"hex", "0x1F", hexToken, "0x1F", false},
+		{"hex with separators", "0xDEAD_BEEF", hexToken, "0xDEAD_BEEF", false},
+		{"binary", "0b1010", hexToken, "0b1010", false},
+		{"binary with separators", "0b10_10", hexToken, "0b10_10", false},
+		{"octal", "0o17", hexToken, "0o17", false},
+		{"octal with separators", "0o1_7", hexToken, "0o1_7", false},
+		{"exponent with no digits", "1e", numberToken, "", true},
+		{"exponent plus with no digits", "1e+", numberToken, "", true},
+		{"exponent minus with no digits", "1e-", numberToken, "", true},
+		{"exponent followed by non-digit", "1ex", numberToken, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toks := lexAll(tt.src)
+			if len(toks) != 1 {
+				t.Fatalf("lexAll(%q) produced %d tokens, want 1: %+v", tt.src, len(toks), toks)
+			}
+			got := toks[0]
+			if tt.wantErr {
+				if got.Kind != badToken || got.Err == nil {
+					t.Fatalf("lexAll(%q) = %+v, want a badToken with a non-nil Err", tt.src, got)
+				}
+				return
+			}
+			if got.Kind != tt.kind {
+				t.Errorf("lexAll(%q) Kind = %v, want %v", tt.src, got.Kind, tt.kind)
+			}
+			if got.Value != tt.value {
+				t.Errorf("lexAll(%q) Value = %q, want %q", tt.src, got.Value, tt.value)
+			}
+			if got.Err != nil {
+				t.Errorf("lexAll(%q) Err = %v, want nil", tt.src, got.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package iolang
+
+// OperatorTable holds the operator precedences and assignment operator
+// mappings that VM.OpShuffle uses to turn the parser's flat message chains
+// into properly nested sends, mirroring Io's own OperatorTable object.
+type OperatorTable struct {
+	Object
+
+	// Operators maps an operator's text to its precedence, 0 (loosest) to
+	// 11 (tightest). Operators of equal precedence are left-associative
+	// except for "**", which is right-associative.
+	Operators map[string]int
+
+	// AssignOperators maps an assignment operator's text to the name of the
+	// slot-assignment message it expands to.
+	AssignOperators map[string]string
+}
+
+// defaultOperators is the precedence table Io scripts get unless something
+// has reassigned OperatorTable's operators slot.
+func defaultOperators() map[string]int {
+	return map[string]int{
+		"||": 0, "or": 0,
+		"&&": 1, "and": 1,
+		"|":  2,
+		"^":  3,
+		"&":  4,
+		"==": 5, "!=": 5,
+		"<": 6, "<=": 6, ">": 6, ">=": 6,
+		"<<": 7, ">>": 7,
+		"+": 8, "-": 8,
+		"*": 9, "%": 9, "/": 9,
+		"**": 10,
+		"?":  11, "@": 11, "@@": 11,
+	}
+}
+
+// defaultAssignOperators is the assignment operator table Io scripts get
+// unless something has reassigned OperatorTable's assignOperators slot.
+func defaultAssignOperators() map[string]string {
+	return map[string]string{
+		":=":  "setSlot",
+		"=":   "updateSlot",
+		"::=": "newSlot",
+	}
+}
+
+func (vm *VM) initOperatorTable() {
+	base, ok := vm.BaseObject.(*Object)
+	if !ok {
+		return
+	}
+	ot := &OperatorTable{
+		Object: Object{
+			Slots:  Slots{},
+			Protos: []Interface{vm.BaseObject},
+		},
+		Operators:       defaultOperators(),
+		AssignOperators: defaultAssignOperators(),
+	}
+	SetSlot(base, "OperatorTable", ot)
+}
+
+// OperatorTable returns the VM's current operator table, or nil if it has
+// not been installed (e.g. in a VM that hasn't finished bootstrapping).
+func (vm *VM) OperatorTable() *OperatorTable {
+	base, ok := vm.BaseObject.(*Object)
+	if !ok {
+		return nil
+	}
+	ot, _ := base.Slots["OperatorTable"].(*OperatorTable)
+	return ot
+}
+
+func (ot *OperatorTable) isOperator(text string) bool {
+	if ot == nil {
+		return false
+	}
+	if _, ok := ot.Operators[text]; ok {
+		return true
+	}
+	_, ok := ot.AssignOperators[text]
+	return ok
+}
+
+func (ot *OperatorTable) precedence(text string) int {
+	if p, ok := ot.Operators[text]; ok {
+		return p
+	}
+	// Assignment operators and anything unrecognized bind loosest of all,
+	// so e.g. "a := b + c" parses as "a := (b + c)".
+	return -1
+}
+
+// OpShuffle rewrites msg's message chain in place according to vm's
+// OperatorTable: operators are reordered by precedence (Shunting-Yard over
+// the linear Next chain) so that e.g. "1 + 2 * 3" becomes "1 +(2 *(3))",
+// and assignment operators are rewritten into their setSlot/updateSlot/
+// newSlot call form. It recurses into every argument list first, so that
+// e.g. "a(1 + 2 * 3)" has its argument shuffled too.
+func (vm *VM) OpShuffle(msg *Message) {
+	if msg == nil {
+		return
+	}
+	ot := vm.OperatorTable()
+	if ot == nil {
+		return
+	}
+	for m := msg; m != nil; m = m.Next {
+		for _, arg := range m.Args {
+			vm.OpShuffle(arg)
+		}
+	}
+	for stmt := msg; stmt != nil; {
+		end := stmt
+		for end.Next != nil && end.Next.Symbol.Kind != SemiSym {
+			end = end.Next
+		}
+		afterSemi := end.Next
+		end.Next = nil
+		vm.shuffleChain(ot, stmt)
+		tail := stmt
+		for tail.Next != nil {
+			tail = tail.Next
+		}
+		tail.Next = afterSemi
+		if afterSemi != nil {
+			afterSemi.Prev = tail
+			stmt = afterSemi.Next
+		} else {
+			stmt = nil
+		}
+	}
+}
+
+// shuffleChain reorders a single statement's message chain (no SemiSym
+// messages in it) in place, so that head continues to be a valid pointer to
+// the start of the (now properly nested) expression.
+func (vm *VM) shuffleChain(ot *OperatorTable, head *Message) {
+	segs, ops := splitOperands(ot, head)
+	if len(ops) == 0 {
+		return
+	}
+	operands := []*Message{segs[0]}
+	var operators []*Message
+	apply := func() {
+		op := operators[len(operators)-1]
+		operators = operators[:len(operators)-1]
+		rhs := operands[len(operands)-1]
+		lhs := operands[len(operands)-2]
+		operands = operands[:len(operands)-2]
+		operands = append(operands, vm.attachOperator(ot, op, lhs, rhs))
+	}
+	for i, op := range ops {
+		for len(operators) > 0 && shouldPop(ot, operators[len(operators)-1], op) {
+			apply()
+		}
+		operators = append(operators, op)
+		operands = append(operands, segs[i+1])
+	}
+	for len(operators) > 0 {
+		apply()
+	}
+	*head = *operands[0]
+}
+
+// shouldPop reports whether the operator on top of the stack should be
+// applied before pushing cur, i.e. whether top binds at least as tightly as
+// cur (or strictly more tightly, for cur's being right-associative).
+func shouldPop(ot *OperatorTable, top, cur *Message) bool {
+	tp, cp := ot.precedence(top.Symbol.Text), ot.precedence(cur.Symbol.Text)
+	if isRightAssociative(ot, cur.Symbol.Text) {
+		return tp > cp
+	}
+	return tp >= cp
+}
+
+// isRightAssociative reports whether operators sharing cur's precedence
+// should group right-to-left: "**" (so "2 ** 3 ** 2" is "2 ** (3 ** 2)") and
+// every assignment operator, which all share precedence -1 (so
+// "a := b := c" is "a := (b := c)", matching Io's actual chained-assignment
+// behavior).
+func isRightAssociative(ot *OperatorTable, text string) bool {
+	if text == "**" {
+		return true
+	}
+	_, ok := ot.AssignOperators[text]
+	return ok
+}
+
+// attachOperator turns lhs and rhs into "lhs op(rhs)": op is appended to the
+// end of lhs's chain and takes rhs as its sole argument, unless op is an
+// assignment operator, in which case it is rewritten into the corresponding
+// setSlot/updateSlot/newSlot call.
+func (vm *VM) attachOperator(ot *OperatorTable, op, lhs, rhs *Message) *Message {
+	if method, ok := ot.AssignOperators[op.Symbol.Text]; ok {
+		return vm.attachAssign(op, method, lhs, rhs)
+	}
+	tail := lhs
+	for tail.Next != nil {
+		tail = tail.Next
+	}
+	tail.Next = op
+	op.Prev = tail
+	op.Args = append([]*Message{rhs}, op.Args...)
+	return lhs
+}
+
+// attachAssign rewrites the assignment "lhs op rhs" into a call to method
+// (setSlot, updateSlot, or newSlot), taking the last message in lhs's chain
+// as the slot name and whatever precedes it (if anything) as the receiver.
+func (vm *VM) attachAssign(op *Message, method string, lhs, rhs *Message) *Message {
+	tail := lhs
+	for tail.Next != nil {
+		tail = tail.Next
+	}
+	name := tail.Symbol.Text
+	op.Symbol = Symbol{Kind: IdentSym, Text: method}
+	op.Position = tail.Position
+	nameArg := &Message{
+		Object:   Object{Slots: vm.DefaultSlots["Message"], Protos: []Interface{vm.BaseObject}},
+		Symbol:   Symbol{Kind: StringSym, String: name},
+		Memo:     vm.NewString(name),
+		Position: tail.Position,
+	}
+	op.Args = []*Message{nameArg, rhs}
+	if tail == lhs {
+		// foo := bar, with no receiver chain before the slot name: op
+		// replaces lhs as the head of the statement, so it must inherit
+		// any comments the lexer attached to lhs or they'd be dropped.
+		op.Comments = tail.Comments
+		return op
+	}
+	tail.Prev.Next = op
+	op.Prev = tail.Prev
+	return lhs
+}
+
+// splitOperands walks head's chain and splits it into the operand segments
+// between operators and the single-message operators separating them, e.g.
+// "a b + c * d" becomes segs = [a b, c, d], ops = [+, *].
+func splitOperands(ot *OperatorTable, head *Message) (segs, ops []*Message) {
+	segStart := head
+	for m := segStart; ; {
+		if m != segStart && m.Symbol.Kind == IdentSym && len(m.Args) == 0 && ot.isOperator(m.Symbol.Text) {
+			m.Prev.Next = nil
+			segs = append(segs, segStart)
+			ops = append(ops, m)
+			next := m.Next
+			m.Next = nil
+			m.Prev = nil
+			if next == nil {
+				return
+			}
+			segStart = next
+			m = next
+			continue
+		}
+		if m.Next == nil {
+			segs = append(segs, segStart)
+			return
+		}
+		m = m.Next
+	}
+}
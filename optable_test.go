@@ -0,0 +1,206 @@
+package iolang
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseShuffled parses src with a fresh VM and runs OpShuffle over the
+// result, the same as VM.DoReader does.
+func parseShuffled(t *testing.T, src string) *Message {
+	t.Helper()
+	vm := NewVM()
+	msg, err := vm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", src, err)
+	}
+	vm.OpShuffle(msg)
+	return msg
+}
+
+func TestOpShuffleArithmeticPrecedence(t *testing.T) {
+	// "1 + 2 * 3" should nest as "1 +(2 *(3))": * binds tighter than +.
+	msg := parseShuffled(t, "1 + 2 * 3")
+
+	if msg.Symbol.Kind != NumSym || msg.Symbol.Num != 1 {
+		t.Fatalf("head = %+v, want the number 1", msg.Symbol)
+	}
+	plus := msg.Next
+	if plus == nil || plus.Symbol.Text != "+" || len(plus.Args) != 1 {
+		t.Fatalf("msg.Next = %+v, want a single-arg \"+\" message", plus)
+	}
+	if plus.Next != nil {
+		t.Fatalf("plus.Next = %+v, want nil: \"+\" should be the end of the statement", plus.Next)
+	}
+
+	two := plus.Args[0]
+	if two.Symbol.Kind != NumSym || two.Symbol.Num != 2 {
+		t.Fatalf("plus.Args[0] = %+v, want the number 2", two.Symbol)
+	}
+	star := two.Next
+	if star == nil || star.Symbol.Text != "*" || len(star.Args) != 1 {
+		t.Fatalf("two.Next = %+v, want a single-arg \"*\" message", star)
+	}
+	three := star.Args[0]
+	if three.Symbol.Kind != NumSym || three.Symbol.Num != 3 {
+		t.Fatalf("star.Args[0] = %+v, want the number 3", three.Symbol)
+	}
+}
+
+func TestOpShuffleRightAssociativePower(t *testing.T) {
+	// "**" is right-associative, so "2 ** 3 ** 2" should nest as
+	// "2 **(3 **(2))", not "2 **(3) **(2)".
+	msg := parseShuffled(t, "2 ** 3 ** 2")
+
+	if msg.Symbol.Num != 2 {
+		t.Fatalf("head = %+v, want the number 2", msg.Symbol)
+	}
+	outer := msg.Next
+	if outer == nil || outer.Symbol.Text != "**" || len(outer.Args) != 1 {
+		t.Fatalf("msg.Next = %+v, want a single-arg \"**\" message", outer)
+	}
+	three := outer.Args[0]
+	if three.Symbol.Num != 3 {
+		t.Fatalf("outer.Args[0] = %+v, want the number 3", three.Symbol)
+	}
+	inner := three.Next
+	if inner == nil || inner.Symbol.Text != "**" || len(inner.Args) != 1 {
+		t.Fatalf("three.Next = %+v, want a single-arg \"**\" message", inner)
+	}
+	if inner.Args[0].Symbol.Num != 2 {
+		t.Fatalf("inner.Args[0] = %+v, want the number 2", inner.Args[0].Symbol)
+	}
+}
+
+func TestOpShuffleAssignment(t *testing.T) {
+	// "x := 1 + 2" rewrites into setSlot("x", 1 +(2)), with the
+	// already-shuffled right-hand side as the rewritten call's second arg.
+	msg := parseShuffled(t, "x := 1 + 2")
+
+	if msg.Symbol.Kind != IdentSym || msg.Symbol.Text != "setSlot" {
+		t.Fatalf("head = %+v, want a setSlot message", msg.Symbol)
+	}
+	if len(msg.Args) != 2 {
+		t.Fatalf("setSlot has %d args, want 2", len(msg.Args))
+	}
+	name := msg.Args[0]
+	if name.Symbol.Kind != StringSym || name.Symbol.String != "x" {
+		t.Fatalf("setSlot's first arg = %+v, want the string \"x\"", name.Symbol)
+	}
+	rhs := msg.Args[1]
+	if rhs.Symbol.Num != 1 {
+		t.Fatalf("setSlot's second arg = %+v, want the number 1", rhs.Symbol)
+	}
+	plus := rhs.Next
+	if plus == nil || plus.Symbol.Text != "+" || len(plus.Args) != 1 || plus.Args[0].Symbol.Num != 2 {
+		t.Fatalf("rhs.Next = %+v, want a single-arg \"+(2)\" message", plus)
+	}
+}
+
+// TestOpShuffleStatementsSurviveSemicolons is a regression test for a bug
+// where OpShuffle's per-statement loop reattached the separator to the
+// original (pre-shuffle) statement head instead of the rebuilt chain's new
+// tail, and then advanced to the separator itself instead of past it. That
+// dropped every statement after the first, and truncated any statement
+// whose shuffled form grew past a single message.
+func TestOpShuffleStatementsSurviveSemicolons(t *testing.T) {
+	msg := parseShuffled(t, "1 + 2;3 + 4")
+
+	if msg.Symbol.Num != 1 {
+		t.Fatalf("statement 1 head = %+v, want the number 1", msg.Symbol)
+	}
+	plus1 := msg.Next
+	if plus1 == nil || plus1.Symbol.Text != "+" || len(plus1.Args) != 1 || plus1.Args[0].Symbol.Num != 2 {
+		t.Fatalf("statement 1 = %+v, want \"1 +(2)\" intact", plus1)
+	}
+
+	sep := plus1.Next
+	if sep == nil || sep.Symbol.Kind != SemiSym {
+		t.Fatalf("plus1.Next = %+v, want the statement separator", sep)
+	}
+
+	stmt2 := sep.Next
+	if stmt2 == nil || stmt2.Symbol.Num != 3 {
+		t.Fatalf("statement 2 head = %+v, want the number 3 (it must not have been dropped)", stmt2)
+	}
+	plus2 := stmt2.Next
+	if plus2 == nil || plus2.Symbol.Text != "+" || len(plus2.Args) != 1 || plus2.Args[0].Symbol.Num != 4 {
+		t.Fatalf("statement 2 = %+v, want \"3 +(4)\" intact", plus2)
+	}
+	if plus2.Next != nil {
+		t.Fatalf("plus2.Next = %+v, want nil: statement 2 should be the end of the chain", plus2.Next)
+	}
+}
+
+// TestOpShuffleAssignmentSurvivesLeadingStatement is a regression test for
+// the same bug as above, in the form it was originally reported: a leading
+// bare statement caused the following assignment's setSlot rewrite to be
+// lost entirely.
+func TestOpShuffleAssignmentSurvivesLeadingStatement(t *testing.T) {
+	msg := parseShuffled(t, "1;foo := 2")
+
+	if msg.Symbol.Num != 1 {
+		t.Fatalf("statement 1 head = %+v, want the number 1", msg.Symbol)
+	}
+	sep := msg.Next
+	if sep == nil || sep.Symbol.Kind != SemiSym {
+		t.Fatalf("msg.Next = %+v, want the statement separator", sep)
+	}
+	setSlot := sep.Next
+	if setSlot == nil || setSlot.Symbol.Text != "setSlot" {
+		t.Fatalf("statement 2 = %+v, want the setSlot rewrite of \"foo := 2\" (it must not have been dropped)", setSlot)
+	}
+	if len(setSlot.Args) != 2 || setSlot.Args[0].Symbol.String != "foo" || setSlot.Args[1].Symbol.Num != 2 {
+		t.Fatalf("setSlot args = %+v, want (\"foo\", 2)", setSlot.Args)
+	}
+}
+
+// TestOpShuffleChainedAssignmentIsRightAssociative is a regression test for
+// a bug where shouldPop treated every assignment operator as left-
+// associative (they all share precedence -1), so "a := b := c" folded the
+// first "a := b" into a setSlot call before the second ":=" was seen, and
+// that call's own Symbol.Text ("setSlot") was then mistaken for the outer
+// assignment's slot name. Assignment must group right-to-left, the same as
+// "**", so this is "a := (b := c)".
+func TestOpShuffleChainedAssignmentIsRightAssociative(t *testing.T) {
+	msg := parseShuffled(t, "a := b := c")
+
+	if msg.Symbol.Text != "setSlot" {
+		t.Fatalf("head = %+v, want a setSlot message", msg.Symbol)
+	}
+	if len(msg.Args) != 2 {
+		t.Fatalf("setSlot has %d args, want 2", len(msg.Args))
+	}
+	name := msg.Args[0]
+	if name.Symbol.Kind != StringSym || name.Symbol.String != "a" {
+		t.Fatalf("outer setSlot's first arg = %+v, want the string \"a\"", name.Symbol)
+	}
+	inner := msg.Args[1]
+	if inner.Symbol.Text != "setSlot" {
+		t.Fatalf("outer setSlot's second arg = %+v, want the inner setSlot rewrite of \"b := c\"", inner.Symbol)
+	}
+	if len(inner.Args) != 2 || inner.Args[0].Symbol.String != "b" || inner.Args[1].Symbol.Text != "c" {
+		t.Fatalf("inner setSlot args = %+v, want (\"b\", c)", inner.Args)
+	}
+}
+
+// TestOpShuffleAssignmentKeepsLeadingComment is a regression test for a bug
+// where attachAssign, on the no-receiver-chain branch (plain "foo := 1"),
+// returned the rewritten setSlot message in place of lhs without copying
+// over lhs's Comments, silently dropping any comment attached to the
+// statement.
+func TestOpShuffleAssignmentKeepsLeadingComment(t *testing.T) {
+	vm := NewVM()
+	msg, err := vm.ParseFile("f.io", strings.NewReader("# leading\nfoo := 1"))
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+	vm.OpShuffle(msg)
+
+	if msg.Symbol.Text != "setSlot" {
+		t.Fatalf("head = %+v, want a setSlot message", msg.Symbol)
+	}
+	if len(msg.Comments) != 1 || msg.Comments[0] != "# leading" {
+		t.Fatalf("head.Comments = %v, want the preserved leading comment", msg.Comments)
+	}
+}
@@ -2,6 +2,8 @@ package iolang
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -9,46 +11,130 @@ import (
 	"strings"
 )
 
+// ErrIncomplete is returned by Parse and ParseFile when the source ends
+// mid-expression, e.g. inside an open "(", "[", "{", a quoted string, or a
+// triple-quoted string. It signals that the input isn't malformed, just
+// unfinished, so a REPL can tell "syntax error" from "user needs to type
+// more" and prompt for another line instead of reporting a failure; see
+// VM.ParseMore and VM.NewREPL.
+var ErrIncomplete = errors.New("iolang: incomplete input")
+
+// incompleteParse holds what Parse or ParseFile had already read from their
+// source when they hit ErrIncomplete, so ParseMore can resume from exactly
+// where they left off instead of needing the original reader.
+type incompleteParse struct {
+	file string
+	buf  []byte
+}
+
+// Position describes a location in Io source, for error messages and for the
+// Message positions used in stack traces. Line and Col are both 1-indexed,
+// so the first rune of a file is at Line 1, Col 1.
+type Position struct {
+	File      string
+	Line, Col int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Parse reads and parses a single expression from source. The resulting
+// Messages have no File recorded in their Position; use ParseFile to parse
+// source that came from a named file.
 func (vm *VM) Parse(source io.Reader) (msg *Message, err error) {
-	src := bufio.NewReader(source)
+	return vm.ParseFile("", source)
+}
+
+// ParseFile reads and parses a single expression from source, recording file
+// as the source file in every Message's Position so later errors can point
+// back to it. If source ends mid-expression, ParseFile returns ErrIncomplete
+// along with a Message suitable for passing to VM.ParseMore.
+func (vm *VM) ParseFile(file string, source io.Reader) (msg *Message, err error) {
+	var buf bytes.Buffer
+	src := bufio.NewReader(io.TeeReader(source, &buf))
 	tokens := make(chan token)
-	go lex(src, tokens)
-	_, msg, err = vm.parseRecurse(-1, src, tokens)
+	go lex(file, src, tokens)
+	_, msg, err = vm.parseRecurse(file, -1, src, tokens)
+	if err == ErrIncomplete {
+		if msg == nil {
+			msg = &Message{Object: Object{Slots: vm.DefaultSlots["Message"], Protos: []Interface{vm.BaseObject}}}
+		}
+		msg.incomplete = &incompleteParse{file: file, buf: append([]byte(nil), buf.Bytes()...)}
+	}
 	return
 }
 
-func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok token, msg *Message, err error) {
+// ParseMore resumes parsing an expression that Parse or ParseFile reported
+// as ErrIncomplete, feeding it the source it had already consumed followed
+// by source, so a REPL can accumulate lines until the expression closes.
+// If prev did not come from an ErrIncomplete result, ParseMore just parses
+// source on its own.
+func (vm *VM) ParseMore(prev *Message, source io.Reader) (*Message, error) {
+	if prev == nil || prev.incomplete == nil {
+		return vm.Parse(source)
+	}
+	combined := io.MultiReader(bytes.NewReader(prev.incomplete.buf), source)
+	return vm.ParseFile(prev.incomplete.file, combined)
+}
+
+func (vm *VM) parseRecurse(file string, open rune, src *bufio.Reader, tokens chan token) (tok token, msg *Message, err error) {
 	msg = &Message{Object: Object{Slots: vm.DefaultSlots["Message"], Protos: []Interface{vm.BaseObject}}}
 	m := msg
 	defer func() {
-		if msg.Symbol.Kind == NoSym {
+		switch {
+		case len(m.Comments) > 0:
+			// A trailing comment with no following message: keep the
+			// otherwise-empty placeholder in the chain so the comment isn't
+			// silently dropped.
+		case msg.Symbol.Kind == NoSym:
 			// We didn't parse any messages.
 			msg = nil
-		} else {
+		case m.Prev != nil:
 			m.Prev.Next = nil
+		default:
+			// m has no Prev because an open-bracket handler above rewound
+			// it to the receiver message (m = m.Prev) before recursing for
+			// the argument list, and that recursive call hit ErrIncomplete
+			// partway through: m is the statement's first message, not the
+			// trailing placeholder this cleanup normally trims.
 		}
 	}()
+	var pendingComments []string
 	for tok = range tokens {
 		switch tok.Kind {
 		case badToken:
-			err = tok.Err
+			if tok.Err == io.ErrUnexpectedEOF {
+				err = ErrIncomplete
+			} else {
+				err = tok.Err
+			}
 			return
+		case commentToken:
+			pendingComments = append(pendingComments, tok.Value)
+			continue
 		case semiToken:
 			if m.IsStart() {
 				// empty statement
 				continue
 			}
-			// TODO: if previous token is in the OperatorTable, ignore newline
+			// Operator precedence and assignment operators are handled
+			// after the full chain is parsed, by VM.OpShuffle.
 			m.Symbol = Symbol{Kind: SemiSym, Text: string(tok.Value)}
+			m.Position = tok.Position()
 		case identToken:
-			// TODO: handle operator precedence
 			m.Symbol = Symbol{Kind: IdentSym, Text: string(tok.Value)}
+			m.Position = tok.Position()
 		case openToken:
 			switch tok.Value {
 			case "(":
 				if m.IsStart() {
 					// This is a call to the empty string slot.
 					m.Symbol = Symbol{Kind: IdentSym}
+					m.Position = tok.Position()
 				} else {
 					// These are the arguments for the previous message.
 					m = m.Prev
@@ -56,14 +142,16 @@ func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok
 				}
 			case "[":
 				m.Symbol = Symbol{Kind: IdentSym, Text: "squareBrackets"}
+				m.Position = tok.Position()
 			case "{":
 				m.Symbol = Symbol{Kind: IdentSym, Text: "curlyBrackets"}
+				m.Position = tok.Position()
 			}
 			var atok token
 			var amsg *Message
-			for atok, amsg, err = vm.parseRecurse(rune(tok.Value[0]), src, tokens); atok.Kind == commaToken; atok, amsg, err = vm.parseRecurse(rune(tok.Value[0]), src, tokens) {
+			for atok, amsg, err = vm.parseRecurse(file, rune(tok.Value[0]), src, tokens); atok.Kind == commaToken; atok, amsg, err = vm.parseRecurse(file, rune(tok.Value[0]), src, tokens) {
 				if amsg == nil {
-					err = fmt.Errorf("empty argument")
+					err = fmt.Errorf("%s: empty argument", atok.Position())
 				}
 				if err != nil {
 					tok = atok
@@ -78,7 +166,7 @@ func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok
 				}
 			} else if len(m.Args) > 1 {
 				if m.Args[len(m.Args)-1] == nil {
-					err = fmt.Errorf("empty argument")
+					err = fmt.Errorf("%s: empty argument", atok.Position())
 				}
 			}
 			if err != nil {
@@ -92,41 +180,43 @@ func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok
 			switch open {
 			case '(':
 				if tok.Value != ")" {
-					err = fmt.Errorf("expected ')', got '%s'", tok.Value)
+					err = fmt.Errorf("%s: expected ')', got '%s'", tok.Position(), tok.Value)
 				}
 			case '[':
 				if tok.Value != "]" {
-					err = fmt.Errorf("expected ']', got '%s'", tok.Value)
+					err = fmt.Errorf("%s: expected ']', got '%s'", tok.Position(), tok.Value)
 				}
 			case '{':
 				if tok.Value != "}" {
-					err = fmt.Errorf("expected '}', got '%s'", tok.Value)
+					err = fmt.Errorf("%s: expected '}', got '%s'", tok.Position(), tok.Value)
 				}
 			default:
-				err = fmt.Errorf("unexpected '%s'", tok.Value)
+				err = fmt.Errorf("%s: unexpected '%s'", tok.Position(), tok.Value)
 			}
 			return
 		case commaToken:
 			if open == -1 {
-				err = fmt.Errorf("bro you can't just comma like that")
+				err = fmt.Errorf("%s: bro you can't just comma like that", tok.Position())
 			}
 			return
 		case numberToken:
 			var f float64
-			f, err = strconv.ParseFloat(tok.Value, 64)
+			f, err = strconv.ParseFloat(stripDigitSeparators(tok.Value), 64)
 			if err != nil {
 				if err.(*strconv.NumError).Err == strconv.ErrRange {
 					err = nil
 				} else {
+					err = fmt.Errorf("%s: %v", tok.Position(), err)
 					return
 				}
 			}
 			m.Symbol = Symbol{Kind: NumSym, Num: f}
 			m.Memo = vm.NewNumber(f)
+			m.Position = tok.Position()
 		case hexToken:
 			var x int64
 			var f float64
-			x, err = strconv.ParseInt(tok.Value, 0, 64)
+			x, err = strconv.ParseInt(stripDigitSeparators(tok.Value), 0, 64)
 			f = float64(x)
 			if err != nil {
 				if err.(*strconv.NumError).Err == strconv.ErrRange {
@@ -137,22 +227,31 @@ func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok
 						f = math.Inf(1)
 					}
 				} else {
+					err = fmt.Errorf("%s: %v", tok.Position(), err)
 					return
 				}
 			}
 			m.Symbol = Symbol{Kind: NumSym, Num: f}
 			m.Memo = vm.NewNumber(f)
+			m.Position = tok.Position()
 		case stringToken:
 			var s string
 			s, err = strconv.Unquote(tok.Value)
 			if err != nil {
+				err = fmt.Errorf("%s: %v", tok.Position(), err)
 				return
 			}
 			m.Symbol = Symbol{Kind: StringSym, String: s}
 			m.Memo = vm.NewString(s)
+			m.Position = tok.Position()
 		case triquoteToken:
 			m.Symbol = Symbol{Kind: StringSym, String: tok.Value[3 : len(tok.Value)-3]}
 			m.Memo = vm.NewString(tok.Value[3 : len(tok.Value)-3])
+			m.Position = tok.Position()
+		}
+		if len(pendingComments) > 0 {
+			m.Comments = pendingComments
+			pendingComments = nil
 		}
 		m.Next = &Message{
 			Object: Object{Slots: vm.DefaultSlots["Message"], Protos: []Interface{vm.BaseObject}},
@@ -160,6 +259,18 @@ func (vm *VM) parseRecurse(open rune, src *bufio.Reader, tokens chan token) (tok
 		}
 		m = m.Next
 	}
+	if len(pendingComments) > 0 {
+		// A comment after the last real token, with nothing following it
+		// before EOF: attach it to the dangling placeholder message so the
+		// deferred cleanup above keeps it instead of dropping it.
+		m.Comments = pendingComments
+		pendingComments = nil
+	}
+	if open != -1 {
+		// The token stream ended without a matching close bracket: the
+		// source was cut off mid-expression, not actually malformed.
+		err = ErrIncomplete
+	}
 	return
 }
 
@@ -189,3 +300,38 @@ func (vm *VM) DoMessage(msg *Message, locals Interface) Interface {
 func (m *Message) IsStart() bool {
 	return m.Prev == nil || m.Prev.Symbol.Kind == SemiSym
 }
+
+// stripDigitSeparators removes the "_" digit separators lexNumber allows
+// within numeric literals (e.g. "1_000_000", "0xDEAD_BEEF"), which
+// strconv.ParseFloat doesn't understand.
+func stripDigitSeparators(s string) string {
+	if !strings.ContainsRune(s, '_') {
+		return s
+	}
+	return strings.ReplaceAll(s, "_", "")
+}
+
+// MessagePosition is a Message method returning the source position at which
+// the message was parsed, as a "file:line:col" string, so Io-level exception
+// handlers can print call-site info the way a proper Io VM does.
+func MessagePosition(vm *VM, target, locals Interface, msg *Message) Interface {
+	return vm.NewString(target.(*Message).Position.String())
+}
+
+// MessagePreviousMessage is a Message method returning the message
+// immediately before target in its chain, or vm.Nil if target is first.
+func MessagePreviousMessage(vm *VM, target, locals Interface, msg *Message) Interface {
+	m := target.(*Message)
+	if m.Prev == nil {
+		return vm.Nil
+	}
+	return m.Prev
+}
+
+func (vm *VM) initMessagePosition() {
+	if vm.DefaultSlots["Message"] == nil {
+		vm.DefaultSlots["Message"] = Slots{}
+	}
+	vm.DefaultSlots["Message"]["position"] = vm.NewCFunction(MessagePosition, "MessagePosition()")
+	vm.DefaultSlots["Message"]["previousMessage"] = vm.NewCFunction(MessagePreviousMessage, "MessagePreviousMessage()")
+}
@@ -0,0 +1,35 @@
+package iolang
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseErrorPositionIsOneIndexed is a regression test for Line and Col
+// both being 1-indexed: "foo(1, 2]" mismatches its open "(" with "]" at the
+// 9th character of the line, so the reported error position must read
+// "1:9", not the 0-indexed "1:8".
+func TestParseErrorPositionIsOneIndexed(t *testing.T) {
+	vm := NewVM()
+	_, err := vm.Parse(strings.NewReader("foo(1, 2]"))
+	if err == nil {
+		t.Fatal("Parse(\"foo(1, 2]\") err = nil, want a mismatched-bracket error")
+	}
+	if want := "1:9: expected ')', got ']'"; err.Error() != want {
+		t.Errorf("Parse(\"foo(1, 2]\") err = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestParseUnterminatedBracketIsIncomplete(t *testing.T) {
+	// Each of these is cut off mid-bracket with the open bracket's receiver
+	// being the very first message of the statement, so the deferred
+	// cleanup in parseRecurse has no preceding message to trim back to.
+	inputs := []string{"foo(", "foo(1", "foo(1, 2", "(1, 2", "[1, 2", "{1, 2"}
+	for _, in := range inputs {
+		vm := NewVM()
+		_, err := vm.Parse(strings.NewReader(in))
+		if err != ErrIncomplete {
+			t.Errorf("Parse(%q) err = %v, want ErrIncomplete", in, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package iolang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// REPL is an interactive Io read-eval-print loop, as constructed by
+// VM.NewREPL. It prompts "io> " for a new statement and "... " while a
+// statement is still open (inside an unclosed bracket or string), using
+// VM.ParseMore to accumulate lines until the expression completes.
+type REPL struct {
+	vm  *VM
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewREPL returns a REPL that reads statements from in and writes prompts
+// and results to out.
+func (vm *VM) NewREPL(in io.Reader, out io.Writer) *REPL {
+	return &REPL{vm: vm, in: bufio.NewReader(in), out: out}
+}
+
+// Run reads, evaluates, and prints statements from r until its input is
+// exhausted.
+func (r *REPL) Run() {
+	var msg *Message
+	prompt := "io> "
+	for {
+		fmt.Fprint(r.out, prompt)
+		line, readErr := r.in.ReadString('\n')
+		if line == "" && readErr != nil {
+			return
+		}
+
+		var parseErr error
+		if msg == nil {
+			msg, parseErr = r.vm.Parse(strings.NewReader(line))
+		} else {
+			msg, parseErr = r.vm.ParseMore(msg, strings.NewReader(line))
+		}
+		switch {
+		case parseErr == ErrIncomplete:
+			prompt = "... "
+			continue
+		case parseErr != nil:
+			fmt.Fprintln(r.out, parseErr)
+			msg, prompt = nil, "io> "
+			continue
+		}
+
+		r.vm.OpShuffle(msg)
+		result := r.vm.DoMessage(msg, r.vm.BaseObject)
+		fmt.Fprintf(r.out, "%v\n", result)
+		msg, prompt = nil, "io> "
+
+		if readErr != nil {
+			return
+		}
+	}
+}